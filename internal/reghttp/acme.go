@@ -0,0 +1,306 @@
+package reghttp
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-acme/lego/v4/certificate"
+	"github.com/go-acme/lego/v4/challenge"
+	"github.com/go-acme/lego/v4/lego"
+	"github.com/go-acme/lego/v4/registration"
+	"github.com/sirupsen/logrus"
+
+	"github.com/regclient/regclient/config"
+)
+
+const defaultACMERenewalThreshold = 30 * 24 * time.Hour
+
+// acmeUser implements lego's acme.User interface, backed by the account key persisted
+// by acmeManager under accounts/<caURL>/<email>/keys/….
+type acmeUser struct {
+	email string
+	reg   *registration.Resource
+	key   crypto.PrivateKey
+}
+
+func (u *acmeUser) GetEmail() string                        { return u.email }
+func (u *acmeUser) GetRegistration() *registration.Resource { return u.reg }
+func (u *acmeUser) GetPrivateKey() crypto.PrivateKey        { return u.key }
+
+// acmeManager obtains and renews a client mTLS certificate for a single host through
+// ACME, persisting the account key, registration, and issued certificate under
+// h.config.ACME.Dir (mirroring the accounts/<caURL>/<email>/keys/… layout used for the
+// ACME account key), and renews it in the background once it's within
+// h.config.ACME.RenewalThreshold of NotAfter. The resulting certificate is exposed
+// through GetClientCertificate so it's picked up by the TLS stack without rebuilding
+// the transport.
+type acmeManager struct {
+	host *config.Host
+	log  *logrus.Logger
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+}
+
+// newACMEManager issues (or loads a still-valid) certificate for h and starts the
+// renewal loop. Returns nil, nil when h has no ACME config.
+func newACMEManager(h *config.Host, log *logrus.Logger) (*acmeManager, error) {
+	if h.ACME == nil {
+		return nil, nil
+	}
+	m := &acmeManager{host: h, log: log, stopCh: make(chan struct{})}
+	if err := m.ensureCert(); err != nil {
+		return nil, err
+	}
+	go m.renewLoop()
+	return m, nil
+}
+
+// GetClientCertificate implements tls.Config.GetClientCertificate, returning the most
+// recently issued or renewed certificate.
+func (m *acmeManager) GetClientCertificate(_ *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.cert == nil {
+		return nil, fmt.Errorf("ACME certificate for %s is not yet available", m.host.Hostname)
+	}
+	return m.cert, nil
+}
+
+// Stop ends the background renewal loop.
+func (m *acmeManager) Stop() {
+	m.stopOnce.Do(func() { close(m.stopCh) })
+}
+
+func (m *acmeManager) accountDir() string {
+	return filepath.Join(m.host.ACME.Dir, "accounts", m.host.ACME.CADirURL, m.host.ACME.Email)
+}
+
+func (m *acmeManager) certPaths() (certPath, keyPath string) {
+	dir := filepath.Join(m.accountDir(), "certificates")
+	return filepath.Join(dir, m.host.Name+".crt"), filepath.Join(dir, m.host.Name+".key")
+}
+
+// ensureCert loads a persisted certificate from disk if it isn't yet within the renewal
+// threshold of expiring, otherwise obtains a new one from the ACME CA.
+func (m *acmeManager) ensureCert() error {
+	certPath, keyPath := m.certPaths()
+	if certPEM, keyPEM, err := readPair(certPath, keyPath); err == nil {
+		if cert, err := tls.X509KeyPair(certPEM, keyPEM); err == nil && !needsRenewal(cert, m.renewalThreshold()) {
+			m.mu.Lock()
+			m.cert = &cert
+			m.mu.Unlock()
+			return nil
+		}
+	}
+	return m.obtain()
+}
+
+func (m *acmeManager) renewalThreshold() time.Duration {
+	if m.host.ACME.RenewalThreshold > 0 {
+		return m.host.ACME.RenewalThreshold
+	}
+	return defaultACMERenewalThreshold
+}
+
+// renewLoop wakes periodically to check whether the current certificate is within its
+// renewal threshold of expiring, re-issuing it through the same ACME account when so.
+func (m *acmeManager) renewLoop() {
+	const checkInterval = 12 * time.Hour
+	t := time.NewTicker(checkInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-m.stopCh:
+			return
+		case <-t.C:
+			m.mu.RLock()
+			cert := m.cert
+			m.mu.RUnlock()
+			if cert == nil || !needsRenewal(*cert, m.renewalThreshold()) {
+				continue
+			}
+			if err := m.obtain(); err != nil {
+				m.log.WithFields(logrus.Fields{
+					"host": m.host.Name,
+					"err":  err,
+				}).Warn("ACME certificate renewal failed, retrying next interval")
+			}
+		}
+	}
+}
+
+// obtain loads or creates the account key and registration, then requests a new
+// certificate for h.config.ACME.Domains, persisting the account and the certificate.
+func (m *acmeManager) obtain() error {
+	key, err := m.loadOrCreateAccountKey()
+	if err != nil {
+		return err
+	}
+	user := &acmeUser{email: m.host.ACME.Email, key: key, reg: m.loadRegistration()}
+	legoCfg := lego.NewConfig(user)
+	legoCfg.CADirURL = m.host.ACME.CADirURL
+	client, err := lego.NewClient(legoCfg)
+	if err != nil {
+		return fmt.Errorf("failed to create ACME client: %w", err)
+	}
+	if err := m.setChallengeProvider(client); err != nil {
+		return err
+	}
+	if user.reg == nil {
+		reg, err := client.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: true})
+		if err != nil {
+			return fmt.Errorf("ACME registration failed: %w", err)
+		}
+		user.reg = reg
+		if err := m.saveRegistration(reg); err != nil {
+			return err
+		}
+	}
+	res, err := client.Certificate.Obtain(certificate.ObtainRequest{
+		Domains: m.host.ACME.Domains,
+		Bundle:  true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to obtain ACME certificate: %w", err)
+	}
+	cert, err := tls.X509KeyPair(res.Certificate, res.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("ACME CA returned a malformed certificate: %w", err)
+	}
+	certPath, keyPath := m.certPaths()
+	if err := writePair(certPath, res.Certificate, keyPath, res.PrivateKey); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.cert = &cert
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *acmeManager) setChallengeProvider(client *lego.Client) error {
+	switch m.host.ACME.Challenge {
+	case config.ACMEChallengeDNS01:
+		if m.host.ACME.DNSProvider == nil {
+			return fmt.Errorf("ACME DNS-01 challenge configured without a DNSProvider")
+		}
+		provider, ok := m.host.ACME.DNSProvider.(challenge.Provider)
+		if !ok {
+			return fmt.Errorf("ACME DNSProvider does not implement challenge.Provider")
+		}
+		return client.Challenge.SetDNS01Provider(provider)
+	default:
+		if m.host.ACME.HTTP01Provider == nil {
+			return fmt.Errorf("ACME HTTP-01 challenge configured without an HTTP01Provider")
+		}
+		provider, ok := m.host.ACME.HTTP01Provider.(challenge.Provider)
+		if !ok {
+			return fmt.Errorf("ACME HTTP01Provider does not implement challenge.Provider")
+		}
+		return client.Challenge.SetHTTP01Provider(provider)
+	}
+}
+
+func (m *acmeManager) loadOrCreateAccountKey() (crypto.PrivateKey, error) {
+	keyPath := filepath.Join(m.accountDir(), "keys", "account.key")
+	if b, err := os.ReadFile(keyPath); err == nil {
+		block, _ := pem.Decode(b)
+		if block == nil {
+			return nil, fmt.Errorf("malformed ACME account key at %s", keyPath)
+		}
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ACME account key: %w", err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create ACME account dir: %w", err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		return nil, fmt.Errorf("failed to persist ACME account key: %w", err)
+	}
+	return key, nil
+}
+
+func (m *acmeManager) loadRegistration() *registration.Resource {
+	b, err := os.ReadFile(filepath.Join(m.accountDir(), "registration.json"))
+	if err != nil {
+		return nil
+	}
+	reg := &registration.Resource{}
+	if err := json.Unmarshal(b, reg); err != nil {
+		return nil
+	}
+	return reg
+}
+
+func (m *acmeManager) saveRegistration(reg *registration.Resource) error {
+	b, err := json.MarshalIndent(reg, "", "  ")
+	if err != nil {
+		return err
+	}
+	regPath := filepath.Join(m.accountDir(), "registration.json")
+	if err := os.MkdirAll(filepath.Dir(regPath), 0o700); err != nil {
+		return fmt.Errorf("failed to create ACME account dir: %w", err)
+	}
+	if err := os.WriteFile(regPath, b, 0o600); err != nil {
+		return fmt.Errorf("failed to persist ACME registration: %w", err)
+	}
+	return nil
+}
+
+// needsRenewal reports whether cert is within threshold of its NotAfter.
+func needsRenewal(cert tls.Certificate, threshold time.Duration) bool {
+	if len(cert.Certificate) == 0 {
+		return true
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return true
+	}
+	return time.Now().Add(threshold).After(leaf.NotAfter)
+}
+
+func readPair(certPath, keyPath string) (certPEM, keyPEM []byte, err error) {
+	certPEM, err = os.ReadFile(certPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPEM, err = os.ReadFile(keyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return certPEM, keyPEM, nil
+}
+
+func writePair(certPath string, certPEM []byte, keyPath string, keyPEM []byte) error {
+	if err := os.MkdirAll(filepath.Dir(certPath), 0o700); err != nil {
+		return fmt.Errorf("failed to create ACME certificate dir: %w", err)
+	}
+	if err := os.WriteFile(certPath, certPEM, 0o600); err != nil {
+		return fmt.Errorf("failed to persist ACME certificate: %w", err)
+	}
+	if err := os.WriteFile(keyPath, keyPEM, 0o600); err != nil {
+		return fmt.Errorf("failed to persist ACME certificate key: %w", err)
+	}
+	return nil
+}
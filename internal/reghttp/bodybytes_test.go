@@ -0,0 +1,76 @@
+package reghttp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/regclient/regclient/config"
+)
+
+// TestBodyBytesRetryResendsFullBody covers the BodyBytes GetBody closure next() wires
+// up: before the fix it captured and returned the same already-drained io.NopCloser on
+// every call, so a retryTransport replay (triggered here by a first-attempt 500) sent an
+// empty body on the second attempt. This is the common manifest-PUT path, which always
+// uses BodyBytes rather than BodyFunc.
+func TestBodyBytesRetryResendsFullBody(t *testing.T) {
+	manifest := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json"}`)
+
+	var (
+		attempts int
+		bodies   [][]byte
+	)
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	mux.HandleFunc("/v2/repo/manifests/latest", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("failed to read request body: %v", err)
+			return
+		}
+		attempts++
+		bodies = append(bodies, body)
+		if attempts == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	c := NewClient(
+		WithConfigHost(func(host string) *config.Host {
+			return &config.Host{Name: host, Hostname: srv.Listener.Addr().String(), TLS: config.TLSDisabled}
+		}),
+		WithDelay(time.Millisecond, 10*time.Millisecond),
+		WithRetryLimit(3),
+	)
+
+	req := &Req{
+		Host:       "registry.example.org",
+		Method:     http.MethodPut,
+		Repository: "repo",
+		Path:       "manifests/latest",
+		BodyBytes:  manifest,
+		BodyLen:    int64(len(manifest)),
+		NoMirrors:  true,
+	}
+	resp, err := c.Do(context.Background(), req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Close()
+
+	if attempts != 2 {
+		t.Fatalf("expected 2 attempts (1 failure + 1 retry), got %d", attempts)
+	}
+	for i, body := range bodies {
+		if !bytes.Equal(body, manifest) {
+			t.Fatalf("attempt %d sent %q, want %q (stale GetBody reader on retry)", i+1, body, manifest)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package reghttp
+
+import (
+	"container/list"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// CachedResponse is a cached GET/HEAD response body, stored along with the headers
+// needed to build a conditional revalidation request (ETag, Last-Modified) and to
+// reconstruct the response on a cache hit.
+type CachedResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Cache stores cached registry responses, keyed by an opaque string produced by
+// cacheKey (repository + path + negotiated Accept header, so multi-arch manifest
+// requests don't collide with OCI-index requests for the same path).
+type Cache interface {
+	Get(key string) (*CachedResponse, bool)
+	Put(key string, cr *CachedResponse)
+	Delete(key string)
+	// DeletePrefix deletes every entry whose key begins with prefix, used to
+	// invalidate all Accept variants cached for a repository+path on a PUT/DELETE.
+	DeletePrefix(prefix string)
+}
+
+// WithCache enables response caching using cache. Cacheable GET/HEAD requests are
+// revalidated with If-None-Match/If-Modified-Since against the cached entry, and a
+// successful PUT/DELETE invalidates the matching entry.
+func WithCache(cache Cache) Opts {
+	return func(c *Client) {
+		c.cache = cache
+	}
+}
+
+// cacheKey builds the cache key for a request, returning "" for requests that aren't
+// scoped to a repository path and therefore can't be cached.
+func cacheKey(req *Req) string {
+	if req.Repository == "" && req.Path == "" {
+		return ""
+	}
+	accept := ""
+	if req.Headers != nil {
+		accept = req.Headers.Get("Accept")
+	}
+	return req.Repository + "|" + req.Path + "|" + accept
+}
+
+// cacheKeyPrefix builds the key prefix shared by every Accept variant cached for req's
+// repository+path, used to invalidate a GET/HEAD entry from a PUT/DELETE to the same
+// path, which rarely carries the same Accept header as the request that populated it.
+func cacheKeyPrefix(req *Req) string {
+	return req.Repository + "|" + req.Path + "|"
+}
+
+// cacheable reports whether req is eligible for response caching.
+func cacheable(req *Req) bool {
+	return (req.Method == http.MethodGet || req.Method == http.MethodHead) && cacheKey(req) != ""
+}
+
+// lruCache is the default in-memory [Cache], evicting the least recently used entry
+// once the total size of cached bodies exceeds maxBytes. The most recently stored entry
+// is always kept, even when its body alone exceeds maxBytes.
+type lruCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	curBytes int64
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type lruEntry struct {
+	key string
+	cr  *CachedResponse
+}
+
+// NewLRUCache returns a [Cache] bounded by the total byte size of cached bodies.
+func NewLRUCache(maxBytes int64) Cache {
+	return &lruCache{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    map[string]*list.Element{},
+	}
+}
+
+func (c *lruCache) Get(key string) (*CachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(e)
+	return e.Value.(*lruEntry).cr, true
+}
+
+func (c *lruCache) Put(key string, cr *CachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.curBytes -= int64(len(e.Value.(*lruEntry).cr.Body))
+		e.Value.(*lruEntry).cr = cr
+		c.ll.MoveToFront(e)
+	} else {
+		c.items[key] = c.ll.PushFront(&lruEntry{key: key, cr: cr})
+	}
+	c.curBytes += int64(len(cr.Body))
+	for c.curBytes > c.maxBytes && c.ll.Len() > 1 {
+		oldest := c.ll.Back()
+		entry := oldest.Value.(*lruEntry)
+		c.ll.Remove(oldest)
+		delete(c.items, entry.key)
+		c.curBytes -= int64(len(entry.cr.Body))
+	}
+}
+
+func (c *lruCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deleteLocked(key)
+}
+
+func (c *lruCache) DeletePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var keys []string
+	for key := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	for _, key := range keys {
+		c.deleteLocked(key)
+	}
+}
+
+func (c *lruCache) deleteLocked(key string) {
+	e, ok := c.items[key]
+	if !ok {
+		return
+	}
+	c.ll.Remove(e)
+	delete(c.items, key)
+	c.curBytes -= int64(len(e.Value.(*lruEntry).cr.Body))
+}
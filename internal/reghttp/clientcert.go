@@ -0,0 +1,137 @@
+package reghttp
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/youmark/pkcs8"
+	"software.sslmate.com/src/go-pkcs12"
+
+	"github.com/regclient/regclient/config"
+)
+
+// resolveClientKeyPassword resolves h.ClientKeyPassword, supporting a literal value, an
+// "env:VAR" reference, or an "exec:/path/to/cmd" helper analogous to Docker's credential
+// helpers, so operators can source the passphrase from a vault instead of plaintext config.
+func resolveClientKeyPassword(h *config.Host) ([]byte, error) {
+	pw := h.ClientKeyPassword
+	switch {
+	case pw == "":
+		return nil, nil
+	case strings.HasPrefix(pw, "env:"):
+		name := strings.TrimPrefix(pw, "env:")
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return nil, fmt.Errorf("client key password env var %q is not set", name)
+		}
+		return []byte(v), nil
+	case strings.HasPrefix(pw, "exec:"):
+		out, err := exec.Command(strings.TrimPrefix(pw, "exec:")).Output()
+		if err != nil {
+			return nil, fmt.Errorf("client key password exec helper failed: %w", err)
+		}
+		return []byte(strings.TrimRight(string(out), "\r\n")), nil
+	default:
+		return []byte(pw), nil
+	}
+}
+
+// loadClientCert builds a [tls.Certificate] from a host's configured client cert/key,
+// supporting unencrypted PEM, encrypted PEM (legacy DEK-Info and PKCS#8), and a single
+// PKCS#12/PFX bundle as an alternative to a separate cert/key pair. Returns nil, nil
+// when the host has no client cert configured.
+func loadClientCert(h *config.Host) (*tls.Certificate, error) {
+	password, err := resolveClientKeyPassword(h)
+	if err != nil {
+		return nil, err
+	}
+	if len(h.ClientP12) > 0 {
+		key, cert, caCerts, err := pkcs12.DecodeChain(h.ClientP12, string(password))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode client p12 bundle: %w", err)
+		}
+		certs := [][]byte{cert.Raw}
+		for _, ca := range caCerts {
+			certs = append(certs, ca.Raw)
+		}
+		return &tls.Certificate{Certificate: certs, PrivateKey: key}, nil
+	}
+	if h.ClientCert == "" || h.ClientKey == "" {
+		return nil, nil
+	}
+	if len(password) == 0 {
+		cert, err := tls.X509KeyPair([]byte(h.ClientCert), []byte(h.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("malformed client key: %w", err)
+		}
+		return &cert, nil
+	}
+	key, err := decryptClientKey([]byte(h.ClientKey), password)
+	if err != nil {
+		return nil, err
+	}
+	return buildTLSCertificate([]byte(h.ClientCert), key)
+}
+
+// decryptClientKey parses an encrypted client key PEM block, trying the modern PKCS#8
+// encrypted form first and falling back to the legacy DEK-Info encrypted PEM header.
+func decryptClientKey(keyPEM, password []byte) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode client key: no PEM block found")
+	}
+	if block.Type == "ENCRYPTED PRIVATE KEY" {
+		key, err := pkcs8.ParsePKCS8PrivateKey(block.Bytes, password)
+		if err != nil {
+			return nil, fmt.Errorf("bad passphrase for client key: %w", err)
+		}
+		return key, nil
+	}
+	//nolint:staticcheck // legacy DEK-Info encrypted PEM, still issued by some internal CAs
+	if !x509.IsEncryptedPEMBlock(block) {
+		return nil, fmt.Errorf("client key is not encrypted, but a ClientKeyPassword was provided")
+	}
+	//nolint:staticcheck // legacy DEK-Info encrypted PEM
+	der, err := x509.DecryptPEMBlock(block, password)
+	if err != nil {
+		return nil, fmt.Errorf("bad passphrase for client key: %w", err)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("malformed client key: %w", err)
+	}
+	return key, nil
+}
+
+// buildTLSCertificate combines a decrypted private key with the certificate chain from
+// certPEM into a [tls.Certificate] usable for mTLS.
+func buildTLSCertificate(certPEM []byte, key crypto.PrivateKey) (*tls.Certificate, error) {
+	var certs [][]byte
+	rest := certPEM
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			certs = append(certs, block.Bytes)
+		}
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("malformed client cert: no CERTIFICATE block found")
+	}
+	return &tls.Certificate{Certificate: certs, PrivateKey: key}, nil
+}
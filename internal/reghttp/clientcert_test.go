@@ -0,0 +1,243 @@
+package reghttp
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/youmark/pkcs8"
+	"software.sslmate.com/src/go-pkcs12"
+
+	"github.com/regclient/regclient/config"
+)
+
+// genCert returns a self-signed cert/key pair for key, used to build the various
+// ClientCert/ClientKey/ClientP12 fixtures below.
+func genCert(t *testing.T, key crypto.Signer) (certDER []byte, certPEM []byte) {
+	t.Helper()
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client-cert-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatalf("failed to create test certificate: %v", err)
+	}
+	return der, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestLoadClientCertKeyAlgorithms(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate EC key: %v", err)
+	}
+	ecKeyP384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate P-384 EC key: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate Ed25519 key: %v", err)
+	}
+
+	t.Run("unencrypted RSA PKCS1", func(t *testing.T) {
+		_, certPEM := genCert(t, rsaKey)
+		keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+		h := &config.Host{ClientCert: string(certPEM), ClientKey: string(keyPEM)}
+		cert, err := loadClientCert(h)
+		if err != nil {
+			t.Fatalf("loadClientCert failed: %v", err)
+		}
+		if cert == nil {
+			t.Fatal("expected a certificate, got nil")
+		}
+	})
+
+	t.Run("unencrypted EC key", func(t *testing.T) {
+		_, certPEM := genCert(t, ecKey)
+		ecDER, err := x509.MarshalECPrivateKey(ecKey)
+		if err != nil {
+			t.Fatalf("failed to marshal EC key: %v", err)
+		}
+		keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER})
+		h := &config.Host{ClientCert: string(certPEM), ClientKey: string(keyPEM)}
+		cert, err := loadClientCert(h)
+		if err != nil {
+			t.Fatalf("loadClientCert failed: %v", err)
+		}
+		if cert == nil {
+			t.Fatal("expected a certificate, got nil")
+		}
+	})
+
+	t.Run("unencrypted EC P-384 key", func(t *testing.T) {
+		_, certPEM := genCert(t, ecKeyP384)
+		ecDER, err := x509.MarshalECPrivateKey(ecKeyP384)
+		if err != nil {
+			t.Fatalf("failed to marshal P-384 EC key: %v", err)
+		}
+		keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER})
+		h := &config.Host{ClientCert: string(certPEM), ClientKey: string(keyPEM)}
+		cert, err := loadClientCert(h)
+		if err != nil {
+			t.Fatalf("loadClientCert failed: %v", err)
+		}
+		if cert == nil {
+			t.Fatal("expected a certificate, got nil")
+		}
+	})
+
+	t.Run("unencrypted Ed25519 key", func(t *testing.T) {
+		_, certPEM := genCert(t, edKey)
+		edDER, err := x509.MarshalPKCS8PrivateKey(edKey)
+		if err != nil {
+			t.Fatalf("failed to marshal Ed25519 key: %v", err)
+		}
+		keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: edDER})
+		h := &config.Host{ClientCert: string(certPEM), ClientKey: string(keyPEM)}
+		cert, err := loadClientCert(h)
+		if err != nil {
+			t.Fatalf("loadClientCert failed: %v", err)
+		}
+		if cert == nil {
+			t.Fatal("expected a certificate, got nil")
+		}
+	})
+
+	t.Run("legacy DEK-Info encrypted PEM", func(t *testing.T) {
+		_, certPEM := genCert(t, rsaKey)
+		//nolint:staticcheck // exercising loadClientCert's legacy DEK-Info fallback path
+		block, err := x509.EncryptPEMBlock(rand.Reader, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey), []byte("hunter2"), x509.PEMCipherAES256)
+		if err != nil {
+			t.Fatalf("failed to encrypt legacy PEM block: %v", err)
+		}
+		keyPEM := pem.EncodeToMemory(block)
+		h := &config.Host{ClientCert: string(certPEM), ClientKey: string(keyPEM), ClientKeyPassword: "hunter2"}
+		cert, err := loadClientCert(h)
+		if err != nil {
+			t.Fatalf("loadClientCert failed: %v", err)
+		}
+		if cert == nil {
+			t.Fatal("expected a certificate, got nil")
+		}
+	})
+
+	t.Run("encrypted PKCS8 RSA key", func(t *testing.T) {
+		_, certPEM := genCert(t, rsaKey)
+		encDER, err := pkcs8.MarshalPrivateKey(rsaKey, []byte("hunter2"), nil)
+		if err != nil {
+			t.Fatalf("failed to marshal encrypted PKCS8 key: %v", err)
+		}
+		keyPEM := pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: encDER})
+		h := &config.Host{ClientCert: string(certPEM), ClientKey: string(keyPEM), ClientKeyPassword: "hunter2"}
+		cert, err := loadClientCert(h)
+		if err != nil {
+			t.Fatalf("loadClientCert failed: %v", err)
+		}
+		if cert == nil {
+			t.Fatal("expected a certificate, got nil")
+		}
+	})
+
+	t.Run("encrypted PKCS8 key with wrong password", func(t *testing.T) {
+		_, certPEM := genCert(t, rsaKey)
+		encDER, err := pkcs8.MarshalPrivateKey(rsaKey, []byte("hunter2"), nil)
+		if err != nil {
+			t.Fatalf("failed to marshal encrypted PKCS8 key: %v", err)
+		}
+		keyPEM := pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: encDER})
+		h := &config.Host{ClientCert: string(certPEM), ClientKey: string(keyPEM), ClientKeyPassword: "wrong"}
+		if _, err := loadClientCert(h); err == nil {
+			t.Fatal("expected an error for a wrong passphrase, got nil")
+		}
+	})
+
+	t.Run("PKCS12 bundle", func(t *testing.T) {
+		certDER, _ := genCert(t, rsaKey)
+		cert, err := x509.ParseCertificate(certDER)
+		if err != nil {
+			t.Fatalf("failed to parse test certificate: %v", err)
+		}
+		p12, err := pkcs12.Encode(rand.Reader, rsaKey, cert, nil, "hunter2")
+		if err != nil {
+			t.Fatalf("failed to encode PKCS12 bundle: %v", err)
+		}
+		h := &config.Host{ClientP12: p12, ClientKeyPassword: "hunter2"}
+		tlsCert, err := loadClientCert(h)
+		if err != nil {
+			t.Fatalf("loadClientCert failed: %v", err)
+		}
+		if tlsCert == nil {
+			t.Fatal("expected a certificate, got nil")
+		}
+	})
+
+	t.Run("PKCS12 bundle with CA chain", func(t *testing.T) {
+		caKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatalf("failed to generate CA key: %v", err)
+		}
+		caTmpl := &x509.Certificate{
+			SerialNumber:          big.NewInt(3),
+			Subject:               pkix.Name{CommonName: "client-cert-test-ca"},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+		}
+		caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+		if err != nil {
+			t.Fatalf("failed to create CA certificate: %v", err)
+		}
+		caCert, err := x509.ParseCertificate(caDER)
+		if err != nil {
+			t.Fatalf("failed to parse CA certificate: %v", err)
+		}
+		leafDER, _ := genCert(t, rsaKey)
+		leafCert, err := x509.ParseCertificate(leafDER)
+		if err != nil {
+			t.Fatalf("failed to parse test certificate: %v", err)
+		}
+		p12, err := pkcs12.EncodeChain(rand.Reader, rsaKey, leafCert, []*x509.Certificate{caCert}, "hunter2")
+		if err != nil {
+			t.Fatalf("failed to encode PKCS12 chain: %v", err)
+		}
+		h := &config.Host{ClientP12: p12, ClientKeyPassword: "hunter2"}
+		tlsCert, err := loadClientCert(h)
+		if err != nil {
+			t.Fatalf("loadClientCert failed: %v", err)
+		}
+		if tlsCert == nil {
+			t.Fatal("expected a certificate, got nil")
+		}
+		if len(tlsCert.Certificate) != 2 {
+			t.Fatalf("expected leaf+CA in the chain, got %d certificates", len(tlsCert.Certificate))
+		}
+	})
+
+	t.Run("no client cert configured", func(t *testing.T) {
+		cert, err := loadClientCert(&config.Host{})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if cert != nil {
+			t.Fatal("expected a nil certificate when none is configured")
+		}
+	})
+}
@@ -10,12 +10,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"math/rand"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
-	"regexp"
-	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -26,17 +26,18 @@ import (
 	_ "crypto/sha512"
 
 	"github.com/sirupsen/logrus"
+	"golang.org/x/net/http2"
+	"golang.org/x/sync/semaphore"
+	"golang.org/x/time/rate"
 
 	"github.com/regclient/regclient/config"
 	"github.com/regclient/regclient/internal/auth"
 	"github.com/regclient/regclient/internal/reqmeta"
 	"github.com/regclient/regclient/types/errs"
-	"github.com/regclient/regclient/types/warning"
 )
 
 var defaultDelayInit, _ = time.ParseDuration("1s")
 var defaultDelayMax, _ = time.ParseDuration("30s")
-var warnRegexp = regexp.MustCompile(`^299\s+-\s+"([^"]+)"`)
 
 const (
 	DefaultRetryLimit = 3
@@ -56,20 +57,35 @@ type Client struct {
 	log           *logrus.Logger
 	userAgent     string
 	mu            sync.Mutex
+	maxInFlight   *semaphore.Weighted
+	maxInFlightLR *semaphore.Weighted
+	isLongRunning func(*Req) bool
+	backoffJitter bool
+	cache         Cache
 }
 
 type clientHost struct {
-	initialized  bool
-	backoffCur   int
-	backoffUntil time.Time
-	config       *config.Host
-	httpClient   *http.Client
-	auth         map[string]auth.Auth
-	newAuth      func() auth.Auth
-	muAuth       sync.Mutex
-	reqFreq      time.Duration
-	reqNext      time.Time
-	muNext       sync.Mutex
+	initialized    bool
+	backoffCur     int
+	backoffUntil   time.Time
+	prevSleep      time.Duration
+	config         *config.Host
+	httpClient     *http.Client
+	registryClient *http.Client
+	auth           map[string]auth.Auth
+	newAuth        func() auth.Auth
+	muAuth         sync.Mutex
+	limiter        *rate.Limiter
+	limiterLimit   rate.Limit
+	limiterBurst   int
+	limiterUntil   time.Time
+	muLimiter      sync.Mutex
+	rttEWMA        time.Duration
+	muRTT          sync.Mutex
+	errRateEWMA    float64
+	muHealth       sync.Mutex
+	acme           *acmeManager
+	probeStop      chan struct{}
 }
 
 // Req is a request to send to a registry.
@@ -84,6 +100,8 @@ type Req struct {
 	BodyLen     int64                         // length of body to send
 	BodyBytes   []byte                        // bytes of the body, overridden by BodyFunc
 	BodyFunc    func() (io.ReadCloser, error) // function to return a new body
+	BodySeeker  io.ReadSeeker                 // seekable body used for a resumable chunked upload, see ChunkSize
+	ChunkSize   int64                         // chunk size for a resumable chunked upload, 0 disables chunking
 	Headers     http.Header                   // headers to send in the request
 	NoPrefix    bool                          // do not include the repository prefix
 	NoMirrors   bool                          // do not send request to a mirror
@@ -103,6 +121,8 @@ type Resp struct {
 	reader           io.Reader
 	readCur, readMax int64
 	throttleDone     func()
+	cacheKey         string
+	cacheBuf         *bytes.Buffer
 }
 
 // Opts is used to configure client options.
@@ -182,6 +202,16 @@ func WithDelay(delayInit time.Duration, delayMax time.Duration) Opts {
 	}
 }
 
+// WithBackoffJitter switches the backoff calculation from `delayInit << backoffCur`
+// to decorrelated jitter (as popularized by AWS' "exponential backoff and jitter"
+// guidance), which spreads out retries from many clients instead of having them
+// retry in lockstep. Off by default to avoid breaking deterministic-timing tests.
+func WithBackoffJitter(enabled bool) Opts {
+	return func(c *Client) {
+		c.backoffJitter = enabled
+	}
+}
+
 // WithHTTPClient uses a specific http client with retryable requests.
 func WithHTTPClient(hc *http.Client) Opts {
 	return func(c *Client) {
@@ -189,6 +219,31 @@ func WithHTTPClient(hc *http.Client) Opts {
 	}
 }
 
+// WithMaxInFlight limits the number of requests in flight across all hosts to n.
+// Requests beyond the limit block on the request context until a slot frees up.
+// Use [WithMaxInFlightLongRunning] to give streaming requests (e.g. blob pulls) a separate ceiling.
+func WithMaxInFlight(n int) Opts {
+	return func(c *Client) {
+		if n > 0 {
+			c.maxInFlight = semaphore.NewWeighted(int64(n))
+		}
+	}
+}
+
+// WithMaxInFlightLongRunning limits the number of long-running requests (as classified by isLongRunning)
+// in flight across all hosts to n, separately from the ceiling set by [WithMaxInFlight]. Every request,
+// long-running or not, holds its slot for the duration of [Resp.Read] / [Resp.Close]; isLongRunning only
+// selects which of the two semaphores a given request counts against, e.g. a blob pull against the
+// (typically smaller) long-running ceiling and a manifest HEAD against the general one.
+func WithMaxInFlightLongRunning(n int, isLongRunning func(*Req) bool) Opts {
+	return func(c *Client) {
+		if n > 0 {
+			c.maxInFlightLR = semaphore.NewWeighted(int64(n))
+			c.isLongRunning = isLongRunning
+		}
+	}
+}
+
 // WithRetryLimit restricts the number of retries (defaults to 5).
 func WithRetryLimit(rl int) Opts {
 	return func(c *Client) {
@@ -205,8 +260,11 @@ func WithLog(log *logrus.Logger) Opts {
 	}
 }
 
-// WithTransport uses a specific http transport with retryable requests.
-func WithTransport(t *http.Transport) Opts {
+// WithTransport uses a specific [http.RoundTripper] as the base of the per-host transport
+// stack (warning extraction, rate limiting, auth, and retries are layered on top of it in
+// getHost; the host TLS settings are applied only when t is an *http.Transport). This is the injection point for custom middleware such as tracing,
+// metrics, or request signing.
+func WithTransport(t http.RoundTripper) Opts {
 	return func(c *Client) {
 		c.httpClient = &http.Client{Transport: t}
 	}
@@ -221,6 +279,9 @@ func WithUserAgent(ua string) Opts {
 
 // Do runs a request, returning the response result.
 func (c *Client) Do(ctx context.Context, req *Req) (*Resp, error) {
+	if req.ChunkSize > 0 && req.BodySeeker != nil {
+		return c.doChunked(ctx, req)
+	}
 	resp := &Resp{
 		ctx:     ctx,
 		client:  c,
@@ -247,13 +308,12 @@ func (resp *Resp) next() error {
 		}
 	}
 	hosts = append(hosts, reqHost)
-	sort.Slice(hosts, sortHostsCmp(hosts, reqHost.config.Name))
+	selectorFor(reqHost.config.MirrorStrategy).sort(hosts, reqHost.config.Name)
 	// loop over requests to mirrors and retries
 	curHost := 0
 	for {
 		backoff := false
 		dropHost := false
-		retryHost := false
 		if len(hosts) == 0 {
 			if err != nil {
 				return err
@@ -271,12 +331,28 @@ func (resp *Resp) next() error {
 		if ctxErr != nil {
 			return ctxErr
 		}
+		// wait for a global in-flight slot, selecting the long-running pool when applicable
+		var inFlightDone func()
+		inFlightSem := c.maxInFlight
+		if c.isLongRunning != nil && c.maxInFlightLR != nil && c.isLongRunning(req) {
+			inFlightSem = c.maxInFlightLR
+		}
+		if inFlightSem != nil {
+			if err := inFlightSem.Acquire(resp.ctx, 1); err != nil {
+				return err
+			}
+			inFlightDone = func() { inFlightSem.Release(1) }
+		}
+
 		// wait for other concurrent requests to this host
 		throttleDone, throttleErr := h.config.Throttle().Acquire(resp.ctx, reqmeta.Data{
 			Kind: req.MetaKind,
 			Size: req.BodyLen + req.ExpectLen + req.TransactLen,
 		})
 		if throttleErr != nil {
+			if inFlightDone != nil {
+				inFlightDone()
+			}
 			return throttleErr
 		}
 
@@ -352,9 +428,13 @@ func (resp *Resp) next() error {
 				httpReq.GetBody = req.BodyFunc
 				httpReq.ContentLength = req.BodyLen
 			} else if len(req.BodyBytes) > 0 {
-				body := io.NopCloser(bytes.NewReader(req.BodyBytes))
-				httpReq.Body = body
-				httpReq.GetBody = func() (io.ReadCloser, error) { return body, nil }
+				httpReq.Body = io.NopCloser(bytes.NewReader(req.BodyBytes))
+				// must return a fresh reader each call: authTransport and retryTransport
+				// both replay the request body via GetBody, and req.BodyBytes is only
+				// read once from a shared slice, so a new reader is cheap to build
+				httpReq.GetBody = func() (io.ReadCloser, error) {
+					return io.NopCloser(bytes.NewReader(req.BodyBytes)), nil
+				}
 				httpReq.ContentLength = req.BodyLen
 			}
 			if len(req.Headers) > 0 {
@@ -372,96 +452,60 @@ func (resp *Resp) next() error {
 					return fmt.Errorf("unable to resume a connection within a range request")
 				}
 			}
-
-			hAuth := h.getAuth(req.Repository)
-			if hAuth != nil {
-				// include docker generated scope to emulate docker clients
-				if req.Repository != "" {
-					scope := "repository:" + req.Repository + ":pull"
-					if req.Method != "HEAD" && req.Method != "GET" {
-						scope = scope + ",push"
+			var cachedEntry *CachedResponse
+			if c.cache != nil && cacheable(req) {
+				if cr, ok := c.cache.Get(cacheKey(req)); ok {
+					cachedEntry = cr
+					if etag := cr.Header.Get("ETag"); etag != "" {
+						httpReq.Header.Set("If-None-Match", etag)
 					}
-					_ = hAuth.AddScope(h.config.Hostname, scope)
-				}
-				// add auth headers
-				err = hAuth.UpdateRequest(httpReq)
-				if err != nil {
-					if errors.Is(err, errs.ErrHTTPUnauthorized) {
-						dropHost = true
-					} else {
-						backoff = true
+					if lm := cr.Header.Get("Last-Modified"); lm != "" {
+						httpReq.Header.Set("If-Modified-Since", lm)
 					}
-					return err
 				}
 			}
 
-			// delay for the rate limit
-			if h.reqFreq > 0 {
-				h.muNext.Lock()
-				if time.Now().Before(h.reqNext) {
-					time.Sleep(time.Until(h.reqNext))
-					h.reqNext = h.reqNext.Add(h.reqFreq)
-				} else {
-					h.reqNext = time.Now().Add(h.reqFreq)
-				}
-				h.muNext.Unlock()
-			}
-
-			// update http client for insecure requests and root certs
-			httpClient := *h.httpClient
+			// auth headers/challenge handling is applied by authTransport, and
+			// retryable statuses/transport errors are retried by retryTransport
+			// (including RTT/error-rate recording for mirror selection), both on
+			// every physical round trip made through h.registryClient
+			httpReq = httpReq.WithContext(contextWithRepository(httpReq.Context(), req.Repository))
 
-			// send request
+			// send request; auth headers are added by authTransport before the
+			// physical round trip, so they aren't yet present on httpReq to log here
 			resp.client.log.WithFields(logrus.Fields{
-				"url":      httpReq.URL.String(),
-				"method":   httpReq.Method,
-				"withAuth": (len(httpReq.Header.Values("Authorization")) > 0),
+				"url":    httpReq.URL.String(),
+				"method": httpReq.Method,
 			}).Debug("http req")
-			resp.resp, err = httpClient.Do(httpReq)
-
+			resp.resp, err = h.registryClient.Do(httpReq)
 			if err != nil {
 				c.log.WithFields(logrus.Fields{
 					"URL": u.String(),
 					"err": err,
 				}).Debug("Request failed")
-				backoff = true
+				dropHost = true
 				return err
 			}
-			// extract any warnings
-			// TODO: move warning handler into RoundTripper to get warnings from each round trip
-			for _, wh := range resp.resp.Header.Values("Warning") {
-				if match := warnRegexp.FindStringSubmatch(wh); len(match) == 2 {
-					// TODO: pass other fields (registry hostname) with structured logging
-					warning.Handle(resp.ctx, resp.client.log, match[1])
-				}
-			}
 			statusCode := resp.resp.StatusCode
+			if statusCode == http.StatusNotModified && cachedEntry != nil {
+				// promote the cached status/header/body into this response so
+				// HTTPResponse() reflects the original 200, not the 304: callers read
+				// Content-Type/Docker-Content-Digest/Content-Length and StatusCode off
+				// of it to extract the manifest digest and media type
+				_ = resp.resp.Body.Close()
+				resp.resp.StatusCode = cachedEntry.StatusCode
+				resp.resp.Header = cachedEntry.Header.Clone()
+				resp.reader = bytes.NewReader(cachedEntry.Body)
+				resp.readMax = int64(len(cachedEntry.Body))
+				resp.done = false
+				return nil
+			}
 			if statusCode < 200 || statusCode >= 300 {
 				switch statusCode {
 				case http.StatusUnauthorized:
-					// if auth can be done, retry same host without delay, otherwise drop/backoff
-					if hAuth != nil {
-						err = hAuth.HandleResponse(resp.resp)
-					} else {
-						err = fmt.Errorf("authentication handler unavailable")
-					}
-					if err != nil {
-						if errors.Is(err, errs.ErrEmptyChallenge) || errors.Is(err, errs.ErrNoNewChallenge) || errors.Is(err, errs.ErrHTTPUnauthorized) {
-							c.log.WithFields(logrus.Fields{
-								"URL": u.String(),
-								"Err": err,
-							}).Debug("Failed to handle auth request")
-						} else {
-							c.log.WithFields(logrus.Fields{
-								"URL": u.String(),
-								"Err": err,
-							}).Warn("Failed to handle auth request")
-						}
-						dropHost = true
-					} else {
-						err = fmt.Errorf("authentication required")
-						retryHost = true
-					}
-					return err
+					// authTransport already ran the auth challenge and retried once;
+					// a 401 reaching here means auth couldn't be resolved for this host
+					dropHost = true
 				case http.StatusNotFound:
 					// if not found, drop mirror for this req, but other requests don't need backoff
 					dropHost = true
@@ -469,8 +513,9 @@ func (resp *Resp) next() error {
 					// if range request error (blob push), drop mirror for this req, but other requests don't need backoff
 					dropHost = true
 				case http.StatusTooManyRequests, http.StatusRequestTimeout, http.StatusGatewayTimeout, http.StatusInternalServerError:
-					// server is likely overloaded, backoff but still retry
-					backoff = true
+					// retryTransport already retried this status up to the backoff limit;
+					// exhausted here means move on to the next mirror
+					dropHost = true
 				default:
 					// all other errors indicate a bigger issue, don't retry and set backoff
 					backoff = true
@@ -511,15 +556,38 @@ func (resp *Resp) next() error {
 				_ = resp.resp.Body.Close()
 				return fmt.Errorf("range request not supported by server")
 			}
+			if c.cache != nil {
+				if statusCode == http.StatusOK && cacheable(req) && resp.resp.Header.Get("ETag") != "" {
+					// tee the body so the cache is populated as the caller reads it
+					resp.cacheKey = cacheKey(req)
+					resp.cacheBuf = &bytes.Buffer{}
+					resp.reader = io.TeeReader(resp.resp.Body, resp.cacheBuf)
+				} else if req.Method == http.MethodPut || req.Method == http.MethodDelete {
+					// a PUT/DELETE rarely carries the same Accept header as the GET
+					// that populated the cache, so invalidate every Accept variant
+					// cached for this repository+path rather than one exact key
+					c.cache.DeletePrefix(cacheKeyPrefix(req))
+				}
+			}
 			return nil
 		}()
 		// return on success
 		if loopErr == nil {
 			resp.throttleDone = throttleDone
+			if inFlightDone != nil {
+				prevDone := resp.throttleDone
+				resp.throttleDone = func() {
+					prevDone()
+					inFlightDone()
+				}
+			}
 			return nil
 		}
 		// backoff, dropHost, and/or go to next host in the list
 		throttleDone()
+		if inFlightDone != nil {
+			inFlightDone()
+		}
 		if backoff {
 			if req.IgnoreErr {
 				// don't set a backoff, immediately drop the host when errors ignored
@@ -539,7 +607,7 @@ func (resp *Resp) next() error {
 		err = loopErr
 		if dropHost {
 			hosts = append(hosts[:curHost], hosts[curHost+1:]...)
-		} else if !retryHost {
+		} else {
 			curHost++
 		}
 	}
@@ -565,6 +633,14 @@ func (resp *Resp) Read(b []byte) (int, error) {
 		if resp.resp.Request.Method == "HEAD" || resp.readCur >= resp.readMax {
 			resp.backoffClear()
 			resp.done = true
+			if resp.cacheBuf != nil {
+				resp.client.cache.Put(resp.cacheKey, &CachedResponse{
+					StatusCode: resp.resp.StatusCode,
+					Header:     resp.resp.Header.Clone(),
+					Body:       resp.cacheBuf.Bytes(),
+				})
+				resp.cacheBuf = nil
+			}
 		} else {
 			// short read, retry?
 			resp.client.log.WithFields(logrus.Fields{
@@ -644,7 +720,26 @@ func (resp *Resp) backoffClear() {
 	c := resp.client
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	ch := c.host[resp.mirror]
+	c.host[resp.mirror].backoffClearLocked(c)
+}
+
+func (resp *Resp) backoffSet() error {
+	c := resp.client
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.host[resp.mirror].backoffSetLocked(c, resp.resp)
+}
+
+func (resp *Resp) backoffUntil() time.Time {
+	c := resp.client
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.host[resp.mirror].backoffUntilLocked()
+}
+
+// backoffClearLocked reduces a backed-off host's backoff count after a success.
+// The caller must hold c.mu.
+func (ch *clientHost) backoffClearLocked(c *Client) {
 	if ch.backoffCur > c.retryLimit {
 		ch.backoffCur = c.retryLimit
 	}
@@ -656,29 +751,44 @@ func (resp *Resp) backoffClear() {
 	}
 }
 
-func (resp *Resp) backoffSet() error {
-	c := resp.client
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	ch := c.host[resp.mirror]
+// backoffSetLocked records a failed attempt against ch, computing the next backoff
+// delay (decorrelated jitter or doubling, per c.backoffJitter) and extending it to
+// cover any Retry-After advertised on httpResp. Returns [errs.ErrBackoffLimit] once
+// c.retryLimit is reached. The caller must hold c.mu.
+func (ch *clientHost) backoffSetLocked(c *Client, httpResp *http.Response) error {
 	ch.backoffCur++
 	// sleep for backoff time
-	sleepTime := c.delayInit << ch.backoffCur
+	var sleepTime time.Duration
+	if c.backoffJitter {
+		// decorrelated jitter: sleep = min(delayMax, rand(delayInit, max(delayInit, prevSleep*3)))
+		upper := c.delayInit
+		if ch.prevSleep*3 > upper {
+			upper = ch.prevSleep * 3
+		}
+		if spread := upper - c.delayInit; spread > 0 {
+			sleepTime = c.delayInit + time.Duration(rand.Int63n(int64(spread)))
+		} else {
+			sleepTime = c.delayInit
+		}
+	} else {
+		sleepTime = c.delayInit << ch.backoffCur
+	}
 	// limit to max delay
 	if sleepTime > c.delayMax {
 		sleepTime = c.delayMax
 	}
-	// check rate limit header
-	if resp.resp != nil && resp.resp.Header.Get("Retry-After") != "" {
-		ras := resp.resp.Header.Get("Retry-After")
-		ra, _ := time.ParseDuration(ras + "s")
-		if ra > c.delayMax {
-			sleepTime = c.delayMax
-		} else if ra > sleepTime {
-			sleepTime = ra
+	// check rate limit header, accepting either delta-seconds or an RFC 7231 HTTP-date
+	if httpResp != nil {
+		if ra, ok := parseRetryAfter(httpResp.Header.Get("Retry-After")); ok {
+			if ra > c.delayMax {
+				sleepTime = c.delayMax
+			} else if ra > sleepTime {
+				sleepTime = ra
+			}
 		}
 	}
 
+	ch.prevSleep = sleepTime
 	ch.backoffUntil = time.Now().Add(sleepTime)
 
 	if ch.backoffCur >= c.retryLimit {
@@ -688,11 +798,9 @@ func (resp *Resp) backoffSet() error {
 	return nil
 }
 
-func (resp *Resp) backoffUntil() time.Time {
-	c := resp.client
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	ch := c.host[resp.mirror]
+// backoffUntilLocked returns the time before which ch should not be sent another
+// request. The caller must hold c.mu.
+func (ch *clientHost) backoffUntilLocked() time.Time {
 	return ch.backoffUntil
 }
 
@@ -724,14 +832,23 @@ func (c *Client) getHost(host string) *clientHost {
 	if h.auth == nil {
 		h.auth = map[string]auth.Auth{}
 	}
-	if h.config.ReqPerSec > 0 && h.reqFreq == 0 {
-		h.reqFreq = time.Duration(float64(time.Second) / h.config.ReqPerSec)
+	if h.config.ReqPerSec > 0 && h.limiter == nil {
+		burst := h.config.ReqBurst
+		if burst <= 0 {
+			burst = int(math.Ceil(h.config.ReqPerSec))
+		}
+		if burst < 1 {
+			burst = 1
+		}
+		h.limiterLimit = rate.Limit(h.config.ReqPerSec)
+		h.limiterBurst = burst
+		h.limiter = rate.NewLimiter(h.limiterLimit, h.limiterBurst)
 	}
 
 	if h.httpClient == nil {
 		h.httpClient = c.httpClient
-		// update http client for insecure requests and root certs
-		if h.config.TLS == config.TLSInsecure || len(c.rootCAPool) > 0 || len(c.rootCADirs) > 0 || h.config.RegCert != "" || (h.config.ClientCert != "" && h.config.ClientKey != "") {
+		// update http client for insecure requests, root certs, and HTTP/2 preference
+		if h.config.TLS == config.TLSInsecure || len(c.rootCAPool) > 0 || len(c.rootCADirs) > 0 || h.config.RegCert != "" || (h.config.ClientCert != "" && h.config.ClientKey != "") || len(h.config.ClientP12) > 0 || h.config.HTTP2 != nil || (h.config.OCSPMode != "" && h.config.OCSPMode != config.OCSPModeOff) || h.config.GetClientCertificate != nil || h.config.ACME != nil {
 			// create a new client and modify the transport
 			httpClient := *c.httpClient
 			if httpClient.Transport == nil {
@@ -758,21 +875,72 @@ func (c *Client) getHost(host string) *clientHost {
 						tlsc.RootCAs = rootPool
 					}
 				}
-				if h.config.ClientCert != "" && h.config.ClientKey != "" {
-					cert, err := tls.X509KeyPair([]byte(h.config.ClientCert), []byte(h.config.ClientKey))
+				if cert, err := loadClientCert(h.config); err != nil {
+					c.log.WithFields(logrus.Fields{
+						"err": err,
+					}).Warn("failed to configure client certs")
+				} else if cert != nil {
+					tlsc.Certificates = []tls.Certificate{*cert}
+				}
+				if verify := newOCSPVerifier(h, &http.Client{Timeout: 10 * time.Second}, c.log); verify != nil {
+					tlsc.VerifyConnection = verify
+				}
+				// a host may supply a rotating client certificate instead of a static
+				// ClientCert/ClientKey pair; this is re-read on every handshake so
+				// rotation doesn't require rebuilding the transport. ACME, when
+				// configured, manages its own issuance/renewal and takes priority over
+				// a manually supplied callback.
+				if h.config.ACME != nil {
+					mgr, err := newACMEManager(h.config, c.log)
 					if err != nil {
 						c.log.WithFields(logrus.Fields{
-							"err": err,
-						}).Warn("failed to configure client certs")
+							"host": h.config.Name,
+							"err":  err,
+						}).Warn("failed to initialize ACME certificate manager")
 					} else {
-						tlsc.Certificates = []tls.Certificate{cert}
+						h.acme = mgr
+						tlsc.GetClientCertificate = mgr.GetClientCertificate
 					}
+				} else if h.config.GetClientCertificate != nil {
+					tlsc.GetClientCertificate = h.config.GetClientCertificate
 				}
 				t.TLSClientConfig = tlsc
+				// HTTP/2 is attempted by default, multiplexing manifest+blob requests
+				// against registries that require it; disable per host for legacy proxies
+				http2Enabled := h.config.HTTP2 == nil || *h.config.HTTP2
+				if http2Enabled {
+					t.ForceAttemptHTTP2 = true
+					if err := http2.ConfigureTransport(t); err != nil {
+						c.log.WithFields(logrus.Fields{
+							"err": err,
+						}).Warn("failed to configure HTTP/2 transport")
+					}
+				} else {
+					t.ForceAttemptHTTP2 = false
+					t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+				}
 				httpClient.Transport = t
 			}
 			h.httpClient = &httpClient
 		}
+		// layer the composable transport stack on top of the host's base transport, so
+		// warning extraction and rate limiting apply to every physical round trip,
+		// including those made by the auth subsystem reusing this same http.Client
+		httpClient := *h.httpClient
+		base := httpClient.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		httpClient.Transport = newWarningTransport(c.log, newRateLimitTransport(h, base))
+		h.httpClient = &httpClient
+
+		// registryClient layers auth and retry on top of the same base transport,
+		// kept separate from h.httpClient so the auth subsystem's own token-fetch
+		// requests (issued through h.httpClient, see WithHTTPClient below) don't
+		// recurse back through this host's own auth handler
+		registryClient := *h.httpClient
+		registryClient.Transport = newRetryTransport(h, c, newAuthTransport(h, c.log, h.httpClient.Transport))
+		h.registryClient = &registryClient
 	}
 
 	if h.newAuth == nil {
@@ -786,11 +954,33 @@ func (c *Client) getHost(host string) *clientHost {
 		}
 	}
 
+	if h.config.MirrorStrategy == config.MirrorStrategyHealth && h.config.HealthCheckInterval > 0 && h.probeStop == nil {
+		h.probeStop = make(chan struct{})
+		go c.healthProbeLoop(host, h)
+	}
+
 	h.initialized = true
 	c.host[host] = h
 	return h
 }
 
+// Close stops the background goroutines (ACME renewal, health probing) that a host may
+// have started, so a discarded Client doesn't leak them. Safe to call on a Client that
+// never started any.
+func (c *Client) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, h := range c.host {
+		if h.acme != nil {
+			h.acme.Stop()
+		}
+		if h.probeStop != nil {
+			close(h.probeStop)
+			h.probeStop = nil
+		}
+	}
+}
+
 // getAuth returns an auth, which may be repository specific.
 func (ch *clientHost) getAuth(repo string) auth.Auth {
 	ch.muAuth.Lock()
@@ -804,6 +994,58 @@ func (ch *clientHost) getAuth(repo string) auth.Auth {
 	return ch.auth[repo]
 }
 
+// applyRateLimitHeaders inspects a 429 response for registry-advertised rate limit hints
+// (X-RateLimit-Remaining/X-RateLimit-Reset, falling back to Retry-After for the reset time)
+// and temporarily throttles the per-host bucket to match rather than relying solely on backoff.
+// The bucket is only narrowed when X-RateLimit-Remaining is positive and works out to a rate
+// below the configured one; a response with Retry-After alone, or with a Remaining of 0,
+// leaves the bucket unchanged. The configured rate and burst are restored once the
+// advertised reset window elapses.
+func (ch *clientHost) applyRateLimitHeaders(header http.Header) {
+	if ch.limiter == nil {
+		return
+	}
+	var resetAt time.Time
+	if resetHdr := header.Get("X-RateLimit-Reset"); resetHdr != "" {
+		if secs, err := strconv.ParseInt(resetHdr, 10, 64); err == nil {
+			resetAt = time.Unix(secs, 0)
+		}
+	}
+	if resetAt.IsZero() {
+		if ra, ok := parseRetryAfter(header.Get("Retry-After")); ok {
+			resetAt = time.Now().Add(ra)
+		}
+	}
+	untilReset := time.Until(resetAt)
+	if resetAt.IsZero() || untilReset <= 0 {
+		return
+	}
+	newLimit := ch.limiterLimit
+	if remHdr := header.Get("X-RateLimit-Remaining"); remHdr != "" {
+		if remaining, err := strconv.Atoi(remHdr); err == nil && remaining >= 0 {
+			newLimit = rate.Limit(float64(remaining) / untilReset.Seconds())
+		}
+	}
+	if newLimit <= 0 || newLimit >= ch.limiterLimit {
+		return
+	}
+	ch.muLimiter.Lock()
+	ch.limiter.SetLimit(newLimit)
+	ch.limiter.SetBurst(1)
+	if ch.limiterUntil.Before(resetAt) {
+		ch.limiterUntil = resetAt
+	}
+	ch.muLimiter.Unlock()
+	time.AfterFunc(untilReset, func() {
+		ch.muLimiter.Lock()
+		defer ch.muLimiter.Unlock()
+		if !time.Now().Before(ch.limiterUntil) {
+			ch.limiter.SetLimit(ch.limiterLimit)
+			ch.limiter.SetBurst(ch.limiterBurst)
+		}
+	})
+}
+
 func (ch *clientHost) AuthCreds() func(h string) auth.Cred {
 	if ch == nil || ch.config == nil {
 		return auth.DefaultCredsFn
@@ -814,6 +1056,22 @@ func (ch *clientHost) AuthCreds() func(h string) auth.Cred {
 	}
 }
 
+// parseRetryAfter parses a Retry-After header in either the delta-seconds form or the
+// RFC 7231 HTTP-date form (e.g. "Wed, 21 Oct 2015 07:28:00 GMT"), returning the duration
+// remaining until that time and whether a value was found.
+func parseRetryAfter(ras string) (time.Duration, bool) {
+	if ras == "" {
+		return 0, false
+	}
+	if secs, err := strconv.ParseInt(ras, 10, 64); err == nil {
+		return time.Duration(secs) * time.Second, true
+	}
+	if when, err := http.ParseTime(ras); err == nil {
+		return time.Until(when), true
+	}
+	return 0, false
+}
+
 // HTTPError returns an error based on the status code.
 func HTTPError(statusCode int) error {
 	switch statusCode {
@@ -831,9 +1089,12 @@ func HTTPError(statusCode int) error {
 }
 
 func makeRootPool(rootCAPool [][]byte, rootCADirs []string, hostname string, hostcert string) (*x509.CertPool, error) {
+	// merge with the system roots so a configured host doesn't need to list every
+	// public root it trusts; fall back to an empty pool on platforms (e.g. Windows)
+	// where the system pool syscall is unsupported and returns nil
 	pool, err := x509.SystemCertPool()
-	if err != nil {
-		return nil, err
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
 	}
 	for _, ca := range rootCAPool {
 		if ok := pool.AppendCertsFromPEM(ca); !ok {
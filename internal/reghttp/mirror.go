@@ -0,0 +1,190 @@
+package reghttp
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/regclient/regclient/config"
+)
+
+// mirrorSelector orders a set of mirror hosts for a request, most preferred first.
+// backoffUntil is always respected as a hard gate ahead of any strategy-specific
+// ordering: a host still backing off is never preferred over one that isn't.
+type mirrorSelector interface {
+	sort(hosts []*clientHost, upstream string)
+}
+
+// selectorFor returns the [mirrorSelector] for a host's configured MirrorStrategy,
+// defaulting to the original fixed priority/backoff ordering.
+func selectorFor(strategy string) mirrorSelector {
+	switch strategy {
+	case config.MirrorStrategyWeighted:
+		return weightedSelector{}
+	case config.MirrorStrategyLatency:
+		return latencySelector{}
+	case config.MirrorStrategyHealth:
+		return healthSelector{}
+	default:
+		return prioritySelector{}
+	}
+}
+
+// prioritySelector is the original behavior: sort by backoff, then Priority ascending,
+// then prefer a mirror over the upstream host.
+type prioritySelector struct{}
+
+func (prioritySelector) sort(hosts []*clientHost, upstream string) {
+	sort.Slice(hosts, sortHostsCmp(hosts, upstream))
+}
+
+// weightedSelector draws hosts proportionally to their configured Weight, spreading
+// load across equal-priority mirrors instead of always preferring the same one.
+type weightedSelector struct{}
+
+func (weightedSelector) sort(hosts []*clientHost, upstream string) {
+	now := time.Now()
+	type weighted struct {
+		host *clientHost
+		key  float64
+	}
+	items := make([]weighted, len(hosts))
+	for i, h := range hosts {
+		w := h.config.Weight
+		if w <= 0 {
+			w = 1
+		}
+		// A-Res (Efraimidis-Spirakis) weighted random sampling: rand()^(1/weight) produces a key where
+		// sorting descending yields a sample proportional to weight without replacement
+		items[i] = weighted{host: h, key: math.Pow(rand.Float64(), 1/float64(w))}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		hi, hj := items[i].host, items[j].host
+		if now.Before(hi.backoffUntil) || now.Before(hj.backoffUntil) {
+			return hi.backoffUntil.Before(hj.backoffUntil)
+		}
+		if hi.config.Priority != hj.config.Priority {
+			return hi.config.Priority < hj.config.Priority
+		}
+		return items[i].key > items[j].key
+	})
+	for i := range hosts {
+		hosts[i] = items[i].host
+	}
+}
+
+// latencySelector prefers the mirror with the lowest observed EWMA round-trip time,
+// updated by recordRTT as requests complete. A host with no observations yet sorts
+// after any host with a known RTT, so it's still tried before being ruled out.
+type latencySelector struct{}
+
+func (latencySelector) sort(hosts []*clientHost, upstream string) {
+	now := time.Now()
+	sort.Slice(hosts, func(i, j int) bool {
+		hi, hj := hosts[i], hosts[j]
+		if now.Before(hi.backoffUntil) || now.Before(hj.backoffUntil) {
+			return hi.backoffUntil.Before(hj.backoffUntil)
+		}
+		ri, rj := hi.currentRTT(), hj.currentRTT()
+		if ri != rj {
+			if ri == 0 {
+				return false
+			}
+			if rj == 0 {
+				return true
+			}
+			return ri < rj
+		}
+		return hi.config.Name != upstream
+	})
+}
+
+// healthSelector scores each host from its recent 5xx/error rate and latency. Both are
+// updated from the request path as results come in, and, when a host sets
+// HealthCheckInterval along with the health MirrorStrategy in its own config, also from a
+// periodic /v2/ probe started in getHost and stopped by
+// [Client.Close], so a mirror going bad between requests is caught instead of only being
+// noticed the next time a real request happens to land on it.
+type healthSelector struct{}
+
+func (healthSelector) sort(hosts []*clientHost, upstream string) {
+	now := time.Now()
+	sort.Slice(hosts, func(i, j int) bool {
+		hi, hj := hosts[i], hosts[j]
+		if now.Before(hi.backoffUntil) || now.Before(hj.backoffUntil) {
+			return hi.backoffUntil.Before(hj.backoffUntil)
+		}
+		si, sj := hi.healthScore(), hj.healthScore()
+		if si != sj {
+			return si < sj
+		}
+		return hi.config.Name != upstream
+	})
+}
+
+// recordRTT updates a host's EWMA round-trip time with a newly observed sample.
+func (ch *clientHost) recordRTT(d time.Duration) {
+	const alpha = 0.2
+	ch.muRTT.Lock()
+	defer ch.muRTT.Unlock()
+	if ch.rttEWMA == 0 {
+		ch.rttEWMA = d
+	} else {
+		ch.rttEWMA = time.Duration(alpha*float64(d) + (1-alpha)*float64(ch.rttEWMA))
+	}
+}
+
+func (ch *clientHost) currentRTT() time.Duration {
+	ch.muRTT.Lock()
+	defer ch.muRTT.Unlock()
+	return ch.rttEWMA
+}
+
+// recordResult updates a host's EWMA error rate with the outcome of a request.
+func (ch *clientHost) recordResult(errored bool) {
+	const alpha = 0.3
+	v := 0.0
+	if errored {
+		v = 1.0
+	}
+	ch.muHealth.Lock()
+	defer ch.muHealth.Unlock()
+	ch.errRateEWMA = alpha*v + (1-alpha)*ch.errRateEWMA
+}
+
+// healthScore combines the EWMA error rate and RTT into a single score, lower is better.
+func (ch *clientHost) healthScore() float64 {
+	ch.muHealth.Lock()
+	errRate := ch.errRateEWMA
+	ch.muHealth.Unlock()
+	rttMs := float64(ch.currentRTT()) / float64(time.Millisecond)
+	return errRate*1000 + rttMs
+}
+
+// healthProbeLoop periodically issues a lightweight request against host to keep
+// healthSelector's RTT/error-rate EWMAs fresh even while no real request is in flight,
+// so a mirror that starts failing is demoted before the next caller hits it. Runs until
+// h.probeStop is closed by [Client.Close].
+func (c *Client) healthProbeLoop(host string, h *clientHost) {
+	t := time.NewTicker(h.config.HealthCheckInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-h.probeStop:
+			return
+		case <-t.C:
+			resp, err := c.Do(context.Background(), &Req{
+				Host:      host,
+				Method:    http.MethodGet,
+				NoMirrors: true,
+				IgnoreErr: true,
+			})
+			if err == nil {
+				_ = resp.Close()
+			}
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package reghttp
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/regclient/regclient/config"
+)
+
+// ocspCache holds parsed OCSP responses in memory, keyed by the leaf's SerialNumber and
+// the leaf's AuthorityKeyId (the issuer key identifier recorded in the leaf, not a hash
+// computed here from the issuer's public key as OCSP's own IssuerKeyHash is), so a revocation check isn't repeated for every connection to
+// the same leaf certificate until the cached response's NextUpdate has passed.
+type ocspCache struct {
+	mu    sync.Mutex
+	items map[string]*ocsp.Response
+}
+
+var defaultOCSPCache = &ocspCache{items: map[string]*ocsp.Response{}}
+
+func ocspCacheKey(leaf *x509.Certificate) string {
+	return leaf.SerialNumber.String() + "||" + string(leaf.AuthorityKeyId)
+}
+
+func (c *ocspCache) get(leaf *x509.Certificate, skew time.Duration) (*ocsp.Response, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.items[ocspCacheKey(leaf)]
+	if !ok {
+		return nil, false
+	}
+	if !r.NextUpdate.IsZero() && time.Now().After(r.NextUpdate.Add(skew)) {
+		return nil, false
+	}
+	return r, true
+}
+
+func (c *ocspCache) put(leaf *x509.Certificate, r *ocsp.Response) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items[ocspCacheKey(leaf)] = r
+}
+
+// newOCSPVerifier returns a [tls.Config.VerifyConnection] callback enforcing OCSP
+// revocation checking for h, or nil if h.config.OCSPMode disables it. A stapled
+// response is preferred; otherwise the leaf's AIA OCSPServer URL is queried directly.
+// A Revoked status always fails the connection; Unknown or a network error fails
+// closed in "hard" mode and is logged and allowed through in "soft" mode.
+func newOCSPVerifier(h *clientHost, httpClient *http.Client, log *logrus.Logger) func(tls.ConnectionState) error {
+	mode := h.config.OCSPMode
+	if mode == "" || mode == config.OCSPModeOff {
+		return nil
+	}
+	skew := h.config.OCSPClockSkew
+	return func(cs tls.ConnectionState) error {
+		if len(cs.VerifiedChains) == 0 || len(cs.VerifiedChains[0]) < 2 {
+			// no issuer in the verified chain to check the leaf against: in hard mode this
+			// is treated the same as a failed check rather than silently skipping revocation
+			return ocspFailure(mode, log, h.config.Hostname, fmt.Errorf("no issuer certificate available to perform an OCSP check for %s", h.config.Hostname))
+		}
+		leaf := cs.VerifiedChains[0][0]
+		issuer := cs.VerifiedChains[0][1]
+		resp, err := fetchOCSPResponse(leaf, issuer, cs.OCSPResponse, httpClient, skew)
+		if err != nil {
+			return ocspFailure(mode, log, h.config.Hostname, fmt.Errorf("OCSP check failed: %w", err))
+		}
+		switch resp.Status {
+		case ocsp.Good:
+			return nil
+		case ocsp.Revoked:
+			return fmt.Errorf("certificate for %s is revoked (OCSP)", h.config.Hostname)
+		default:
+			return ocspFailure(mode, log, h.config.Hostname, fmt.Errorf("OCSP status unknown for %s", h.config.Hostname))
+		}
+	}
+}
+
+func ocspFailure(mode string, log *logrus.Logger, hostname string, err error) error {
+	if mode == config.OCSPModeHard {
+		return err
+	}
+	log.WithFields(logrus.Fields{
+		"host": hostname,
+		"err":  err,
+	}).Warn("OCSP check failed, continuing since OCSPMode is soft")
+	return nil
+}
+
+// fetchOCSPResponse prefers the stapled response and otherwise queries the leaf's AIA
+// OCSPServer URL, respecting a cached response until its NextUpdate plus skew. A positive
+// skew tolerates clock drift between this host and the OCSP responder by treating a
+// response as still fresh briefly past its NextUpdate, instead of re-querying immediately.
+func fetchOCSPResponse(leaf, issuer *x509.Certificate, staple []byte, httpClient *http.Client, skew time.Duration) (*ocsp.Response, error) {
+	if len(staple) > 0 {
+		resp, err := ocsp.ParseResponseForCert(staple, leaf, issuer)
+		if err == nil {
+			return resp, nil
+		}
+	}
+	if cached, ok := defaultOCSPCache.get(leaf, skew); ok {
+		return cached, nil
+	}
+	if len(leaf.OCSPServer) == 0 {
+		return nil, fmt.Errorf("no stapled OCSP response and no OCSP server in certificate")
+	}
+	reqBytes, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build OCSP request: %w", err)
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, leaf.OCSPServer[0], nil)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/ocsp-request")
+	httpReq.Body = io.NopCloser(bytes.NewReader(reqBytes))
+	httpReq.ContentLength = int64(len(reqBytes))
+	httpResp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach OCSP responder: %w", err)
+	}
+	defer httpResp.Body.Close()
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := ocsp.ParseResponseForCert(body, leaf, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse OCSP response: %w", err)
+	}
+	defaultOCSPCache.put(leaf, resp)
+	return resp, nil
+}
@@ -0,0 +1,141 @@
+package reghttp
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// issuerAndLeaf returns a self-signed issuer and a leaf it signs, with leaf.OCSPServer
+// pointed at responderURL, the minimum chain fetchOCSPResponse needs to build a request.
+func issuerAndLeaf(t *testing.T, responderURL string) (issuerKey *rsa.PrivateKey, issuer, leaf *x509.Certificate) {
+	t.Helper()
+	issuerKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate issuer key: %v", err)
+	}
+	issuerTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-issuer"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		SubjectKeyId:          []byte{1},
+	}
+	issuerDER, err := x509.CreateCertificate(rand.Reader, issuerTmpl, issuerTmpl, &issuerKey.PublicKey, issuerKey)
+	if err != nil {
+		t.Fatalf("failed to create issuer certificate: %v", err)
+	}
+	issuer, err = x509.ParseCertificate(issuerDER)
+	if err != nil {
+		t.Fatalf("failed to parse issuer certificate: %v", err)
+	}
+
+	leafKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate leaf key: %v", err)
+	}
+	leafTmpl := &x509.Certificate{
+		SerialNumber:   big.NewInt(2),
+		Subject:        pkix.Name{CommonName: "test-leaf"},
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       time.Now().Add(time.Hour),
+		OCSPServer:     []string{responderURL},
+		AuthorityKeyId: issuer.SubjectKeyId,
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTmpl, issuer, &leafKey.PublicKey, issuerKey)
+	if err != nil {
+		t.Fatalf("failed to create leaf certificate: %v", err)
+	}
+	leaf, err = x509.ParseCertificate(leafDER)
+	if err != nil {
+		t.Fatalf("failed to parse leaf certificate: %v", err)
+	}
+	return issuerKey, issuer, leaf
+}
+
+// TestFetchOCSPResponseAndClockSkew runs a fake OCSP responder (self-signed issuer/leaf,
+// a handler that always answers Good) and checks that fetchOCSPResponse both parses its
+// response and honors the configurable clock skew added against the cache's NextUpdate.
+func TestFetchOCSPResponseAndClockSkew(t *testing.T) {
+	var (
+		respBody []byte
+		hits     int
+	)
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		_, _ = w.Write(respBody)
+	})
+
+	issuerKey, issuer, leaf := issuerAndLeaf(t, srv.URL)
+
+	// NextUpdate is already in the past, so a cached entry is only reused once skew
+	// covers the gap
+	respTmpl := ocsp.Response{
+		Status:       ocsp.Good,
+		SerialNumber: leaf.SerialNumber,
+		ThisUpdate:   time.Now().Add(-time.Hour),
+		NextUpdate:   time.Now().Add(-time.Second),
+	}
+	var err error
+	respBody, err = ocsp.CreateResponse(issuer, issuer, respTmpl, issuerKey)
+	if err != nil {
+		t.Fatalf("failed to create fake OCSP response: %v", err)
+	}
+
+	// isolate this test from cache state left by any other test in the package
+	defaultOCSPCache = &ocspCache{items: map[string]*ocsp.Response{}}
+
+	resp, err := fetchOCSPResponse(leaf, issuer, nil, srv.Client(), 0)
+	if err != nil {
+		t.Fatalf("fetchOCSPResponse failed: %v", err)
+	}
+	if resp.Status != ocsp.Good {
+		t.Fatalf("expected status Good, got %d", resp.Status)
+	}
+	if hits != 1 {
+		t.Fatalf("expected the responder to be queried once, got %d hits", hits)
+	}
+
+	// NextUpdate has already passed; with no skew, the expired cache entry must not be
+	// reused and the responder is queried again
+	if _, err := fetchOCSPResponse(leaf, issuer, nil, srv.Client(), 0); err != nil {
+		t.Fatalf("fetchOCSPResponse failed: %v", err)
+	}
+	if hits != 2 {
+		t.Fatalf("expected a fresh request when skew doesn't cover the expired NextUpdate, got %d hits", hits)
+	}
+
+	// a skew long enough to cover how far NextUpdate is in the past reuses the cache
+	// instead of re-querying
+	defaultOCSPCache.put(leaf, resp)
+	if _, err := fetchOCSPResponse(leaf, issuer, nil, srv.Client(), time.Hour); err != nil {
+		t.Fatalf("fetchOCSPResponse failed: %v", err)
+	}
+	if hits != 2 {
+		t.Fatalf("expected the cached response to be reused once skew covers the expired NextUpdate, got %d hits", hits)
+	}
+}
+
+func TestFetchOCSPResponseNoServer(t *testing.T) {
+	issuerKey, issuer, leaf := issuerAndLeaf(t, "")
+	leaf.OCSPServer = nil
+	_ = issuerKey
+	defaultOCSPCache = &ocspCache{items: map[string]*ocsp.Response{}}
+	if _, err := fetchOCSPResponse(leaf, issuer, nil, http.DefaultClient, 0); err == nil {
+		t.Fatal("expected an error when the leaf has no OCSP server and no staple")
+	}
+}
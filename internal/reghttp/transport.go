@@ -0,0 +1,219 @@
+package reghttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/regclient/regclient/types/errs"
+	"github.com/regclient/regclient/types/warning"
+)
+
+// ctxKeyRepository is the context key next() uses to pass a request's repository
+// scope down to authTransport, which otherwise only sees the physical *http.Request.
+type ctxKeyRepository struct{}
+
+// contextWithRepository returns a context carrying repo, read back by authTransport.
+func contextWithRepository(ctx context.Context, repo string) context.Context {
+	return context.WithValue(ctx, ctxKeyRepository{}, repo)
+}
+
+func repositoryFromContext(ctx context.Context) string {
+	repo, _ := ctx.Value(ctxKeyRepository{}).(string)
+	return repo
+}
+
+var warnRegexp = regexp.MustCompile(`^299\s+-\s+"([^"]+)"`)
+
+// warningTransport extracts RFC 7234 Warning headers from every physical round trip
+// (including auth token exchanges that reuse the host's http.Client) and reports them
+// through [warning.Handle]. This used to only run on the final response seen by
+// Resp.next(), missing warnings surfaced during auth challenge handling.
+type warningTransport struct {
+	base http.RoundTripper
+	log  *logrus.Logger
+}
+
+func newWarningTransport(log *logrus.Logger, base http.RoundTripper) http.RoundTripper {
+	return &warningTransport{base: base, log: log}
+}
+
+func (t *warningTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(r)
+	if resp != nil {
+		for _, wh := range resp.Header.Values("Warning") {
+			if match := warnRegexp.FindStringSubmatch(wh); len(match) == 2 {
+				// TODO: pass other fields (registry hostname) with structured logging
+				warning.Handle(r.Context(), t.log, match[1])
+			}
+		}
+	}
+	return resp, err
+}
+
+// rateLimitTransport waits for a token from the host's rate limiter before every
+// physical round trip, so callers that reuse the host's http.Client directly (e.g. the
+// auth subsystem fetching a bearer token) are rate limited the same as registry requests.
+type rateLimitTransport struct {
+	host *clientHost
+	base http.RoundTripper
+}
+
+func newRateLimitTransport(host *clientHost, base http.RoundTripper) http.RoundTripper {
+	return &rateLimitTransport{host: host, base: base}
+}
+
+func (t *rateLimitTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if t.host.limiter != nil {
+		if err := t.host.limiter.Wait(r.Context()); err != nil {
+			return nil, err
+		}
+	}
+	return t.base.RoundTrip(r)
+}
+
+// authTransport applies a host's auth handler to every registry round trip: it adds
+// the docker-generated pull/push scope and the current auth headers before sending,
+// and on a 401 response runs the challenge through auth.HandleResponse and retries the
+// request once with the refreshed headers. A host with no auth handler passes through
+// unmodified. A request with no repository in its context gets no scope added, but
+// still has its auth headers applied and a 401 challenge handled. Token fetches issued
+// against the host's plain http.Client never reach this transport. This is layered underneath
+// retryTransport, which is only aware of HTTP semantics, not registry auth challenges.
+type authTransport struct {
+	host *clientHost
+	log  *logrus.Logger
+	base http.RoundTripper
+}
+
+func newAuthTransport(host *clientHost, log *logrus.Logger, base http.RoundTripper) http.RoundTripper {
+	return &authTransport{host: host, log: log, base: base}
+}
+
+func (t *authTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	repo := repositoryFromContext(r.Context())
+	hAuth := t.host.getAuth(repo)
+	if hAuth == nil {
+		return t.base.RoundTrip(r)
+	}
+	if repo != "" {
+		scope := "repository:" + repo + ":pull"
+		if r.Method != http.MethodHead && r.Method != http.MethodGet {
+			scope += ",push"
+		}
+		_ = hAuth.AddScope(t.host.config.Hostname, scope)
+	}
+	if err := hAuth.UpdateRequest(r); err != nil {
+		// a hard auth/config failure, not a transport error: wrap it so retryTransport
+		// doesn't burn the retry limit and backoff sleeps on it before giving up
+		return nil, fmt.Errorf("%w: %w", errs.ErrNotRetryable, err)
+	}
+	resp, err := t.base.RoundTrip(r)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	if hErr := hAuth.HandleResponse(resp); hErr != nil {
+		if errors.Is(hErr, errs.ErrEmptyChallenge) || errors.Is(hErr, errs.ErrNoNewChallenge) || errors.Is(hErr, errs.ErrHTTPUnauthorized) {
+			t.log.WithFields(logrus.Fields{"URL": r.URL.String(), "Err": hErr}).Debug("Failed to handle auth request")
+		} else {
+			t.log.WithFields(logrus.Fields{"URL": r.URL.String(), "Err": hErr}).Warn("Failed to handle auth request")
+		}
+		// leave the 401 for the caller (next()'s mirror loop) to classify
+		return resp, nil
+	}
+	_ = resp.Body.Close()
+	if r.GetBody != nil {
+		body, gerr := r.GetBody()
+		if gerr != nil {
+			return nil, gerr
+		}
+		r.Body = body
+	}
+	if err := hAuth.UpdateRequest(r); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(r)
+}
+
+// retryTransport retries a registry round trip on a transport error or a
+// retryable status (429, 408, 504, 500), honoring a host's backoff delay and
+// recording RTT/error-rate samples for mirror selection on every physical attempt.
+// A 429 has its rate limit headers passed to applyRateLimitHeaders before retrying. Retries stop once the host's configured retry limit is reached, returning
+// the last response/error for next()'s mirror loop to classify (drop vs. backoff).
+type retryTransport struct {
+	host   *clientHost
+	client *Client
+	base   http.RoundTripper
+}
+
+func newRetryTransport(host *clientHost, client *Client, base http.RoundTripper) http.RoundTripper {
+	return &retryTransport{host: host, client: client, base: base}
+}
+
+func (t *retryTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	for {
+		t.client.mu.Lock()
+		bu := t.host.backoffUntilLocked()
+		t.client.mu.Unlock()
+		if !bu.IsZero() && bu.After(time.Now()) {
+			select {
+			case <-r.Context().Done():
+				return nil, r.Context().Err()
+			case <-time.After(time.Until(bu)):
+			}
+		}
+
+		reqStart := time.Now()
+		resp, err := t.base.RoundTrip(r)
+		t.host.recordRTT(time.Since(reqStart))
+
+		if errors.Is(err, errs.ErrNotRetryable) {
+			// a hard auth/config failure from authTransport, not a transient one:
+			// return immediately instead of burning the retry limit and backoff sleeps
+			return resp, err
+		}
+
+		retryable := err != nil
+		if !retryable {
+			t.host.recordResult(resp.StatusCode >= 500)
+			switch resp.StatusCode {
+			case http.StatusTooManyRequests:
+				t.host.applyRateLimitHeaders(resp.Header)
+				retryable = true
+			case http.StatusRequestTimeout, http.StatusGatewayTimeout, http.StatusInternalServerError:
+				retryable = true
+			}
+		} else {
+			t.host.recordResult(true)
+		}
+		if !retryable {
+			t.client.mu.Lock()
+			t.host.backoffClearLocked(t.client)
+			t.client.mu.Unlock()
+			return resp, err
+		}
+
+		if resp != nil {
+			_ = resp.Body.Close()
+		}
+		t.client.mu.Lock()
+		boErr := t.host.backoffSetLocked(t.client, resp)
+		t.client.mu.Unlock()
+		if boErr != nil {
+			// retry limit reached, return the last result for the mirror loop to drop
+			return resp, err
+		}
+		if r.GetBody != nil {
+			body, gerr := r.GetBody()
+			if gerr != nil {
+				return resp, err
+			}
+			r.Body = body
+		}
+	}
+}
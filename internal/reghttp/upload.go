@@ -0,0 +1,162 @@
+package reghttp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// maxChunkResumes bounds how many times doChunked will re-query the upload offset and
+// resume after a failed chunk, so a server that keeps failing the same chunk without
+// ever advancing the committed offset fails the upload instead of looping forever.
+const maxChunkResumes = 10
+
+// doChunked performs a resumable chunked upload: the body is split into PATCH requests
+// carrying a Content-Range header, each tracking the server-acknowledged offset returned
+// in the Range response header, followed by a final PUT to commit the upload. On a
+// retryable failure or a 416 (Requested Range Not Satisfiable), the upload URL is
+// re-queried to discover the true committed offset and the source body is seeked there,
+// so a mid-upload disconnect resumes instead of restarting from byte 0. A resume that
+// doesn't advance the committed offset, or that happens more than maxChunkResumes times,
+// aborts instead of retrying indefinitely.
+func (c *Client) doChunked(ctx context.Context, req *Req) (*Resp, error) {
+	size := req.BodyLen
+	uploadURL := req.DirectURL
+	var offset int64
+	var resumes int
+	lastResumeOffset := int64(-1) // sentinel: no resume attempted yet
+	for offset < size {
+		end := offset + req.ChunkSize
+		if end > size {
+			end = size
+		}
+		chunkOffset, chunkEnd := offset, end
+		chunkHeaders := req.Headers.Clone()
+		if chunkHeaders == nil {
+			chunkHeaders = http.Header{}
+		}
+		chunkHeaders.Set("Content-Range", fmt.Sprintf("bytes %d-%d/*", chunkOffset, chunkEnd-1))
+		chunkReq := &Req{
+			MetaKind:   req.MetaKind,
+			Host:       req.Host,
+			Method:     http.MethodPatch,
+			DirectURL:  uploadURL,
+			Repository: req.Repository,
+			BodyLen:    chunkEnd - chunkOffset,
+			BodyFunc: func() (io.ReadCloser, error) {
+				// called again by GetBody on a retry or redirect, so the body must be
+				// re-seeked and re-limited each time rather than reused once consumed
+				if _, err := req.BodySeeker.Seek(chunkOffset, io.SeekStart); err != nil {
+					return nil, fmt.Errorf("failed to seek upload body to offset %d: %w", chunkOffset, err)
+				}
+				return io.NopCloser(io.LimitReader(req.BodySeeker, chunkEnd-chunkOffset)), nil
+			},
+			Headers:   chunkHeaders,
+			NoMirrors: req.NoMirrors,
+		}
+		resp := &Resp{ctx: ctx, client: c, req: chunkReq}
+		err := resp.next()
+		if err != nil {
+			resumes++
+			if resumes > maxChunkResumes {
+				return nil, fmt.Errorf("chunked upload failed after %d resume attempts: %w", maxChunkResumes, err)
+			}
+			newOffset, qErr := c.queryUploadOffset(ctx, req, uploadURL)
+			if qErr != nil {
+				return nil, err
+			}
+			if lastResumeOffset >= 0 && newOffset <= lastResumeOffset {
+				return nil, fmt.Errorf("chunked upload not progressing, server still reports offset %d after a prior resume: %w", newOffset, err)
+			}
+			lastResumeOffset = newOffset
+			offset = newOffset
+			continue
+		}
+		uploadURL = locationFromResp(resp.resp, uploadURL)
+		offset = rangeEndFromResp(resp.resp, end)
+		_ = resp.Close()
+	}
+
+	// final request to commit the upload; DirectURL overrides Query (see its doc
+	// comment), so the digest= param the caller set in req.Query must be merged
+	// into uploadURL directly or the registry has no digest to verify the blob against
+	finalURL := *uploadURL
+	finalQuery := finalURL.Query()
+	for k, vs := range req.Query {
+		for _, v := range vs {
+			finalQuery.Set(k, v)
+		}
+	}
+	finalURL.RawQuery = finalQuery.Encode()
+	finalReq := &Req{
+		MetaKind:   req.MetaKind,
+		Host:       req.Host,
+		Method:     req.Method,
+		DirectURL:  &finalURL,
+		Repository: req.Repository,
+		Headers:    req.Headers,
+		NoMirrors:  req.NoMirrors,
+		ExpectLen:  req.ExpectLen,
+	}
+	finalResp := &Resp{ctx: ctx, client: c, req: finalReq, readMax: req.ExpectLen}
+	err := finalResp.next()
+	return finalResp, err
+}
+
+// queryUploadOffset issues a status GET against the upload URL to discover the offset
+// the server has actually committed, used to resume a chunked upload after a disconnect.
+func (c *Client) queryUploadOffset(ctx context.Context, orig *Req, uploadURL *url.URL) (int64, error) {
+	statusReq := &Req{
+		Host:       orig.Host,
+		Method:     http.MethodGet,
+		DirectURL:  uploadURL,
+		Repository: orig.Repository,
+		NoMirrors:  orig.NoMirrors,
+		IgnoreErr:  true,
+	}
+	resp := &Resp{ctx: ctx, client: c, req: statusReq}
+	err := resp.next()
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Close()
+	return rangeEndFromResp(resp.resp, 0), nil
+}
+
+// locationFromResp resolves a Location header against the previous upload URL, returning
+// fallback when the header is absent or unparsable.
+func locationFromResp(hResp *http.Response, fallback *url.URL) *url.URL {
+	if hResp == nil {
+		return fallback
+	}
+	if u, err := hResp.Location(); err == nil {
+		return u
+	}
+	return fallback
+}
+
+// rangeEndFromResp parses the exclusive end offset (the next byte to send) from a
+// "Range: bytes=0-N" style response header, returning fallback when absent or unparsable.
+func rangeEndFromResp(hResp *http.Response, fallback int64) int64 {
+	if hResp == nil {
+		return fallback
+	}
+	rangeHdr := hResp.Header.Get("Range")
+	if rangeHdr == "" {
+		return fallback
+	}
+	rangeHdr = strings.TrimPrefix(rangeHdr, "bytes=")
+	parts := strings.SplitN(rangeHdr, "-", 2)
+	if len(parts) != 2 {
+		return fallback
+	}
+	end, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return fallback
+	}
+	return end + 1
+}
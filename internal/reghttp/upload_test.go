@@ -0,0 +1,161 @@
+package reghttp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/regclient/regclient/config"
+)
+
+// TestDoChunkedRetriesChunkAfterServerError exercises doChunked's chunked upload against a
+// fake registry: the first PATCH attempt fails with a 500, forcing retryTransport to
+// resend the request body via GetBody (wired to the chunk's BodyFunc). Before the
+// BodyFunc fix, GetBody returned an already-drained reader on the retry and the server
+// would observe a short or empty body on the second attempt; this asserts the full chunk
+// arrives intact both times, and that the final commit PUT still carries the caller's
+// digest= query param, which DirectURL would otherwise cause to be dropped.
+func TestDoChunkedRetriesChunkAfterServerError(t *testing.T) {
+	data := []byte("the quick brown fox jumps over the lazy dog")
+
+	var (
+		patchAttempts int
+		patchBodies   [][]byte
+		commitQuery   url.Values
+		commitSeen    bool
+	)
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	mux.HandleFunc("/upload", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPatch:
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				t.Errorf("failed to read chunk body: %v", err)
+				return
+			}
+			patchAttempts++
+			patchBodies = append(patchBodies, body)
+			if patchAttempts == 1 {
+				// fail the first physical attempt to force a retry with a re-sent body
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(data)-1))
+			w.Header().Set("Location", srv.URL+"/upload")
+			w.WriteHeader(http.StatusAccepted)
+		case http.MethodPut:
+			commitSeen = true
+			commitQuery = r.URL.Query()
+			w.WriteHeader(http.StatusCreated)
+		default:
+			t.Errorf("unexpected method on /upload: %s", r.Method)
+		}
+	})
+
+	c := NewClient(
+		WithConfigHost(func(host string) *config.Host { return &config.Host{Name: host} }),
+		WithDelay(time.Millisecond, 10*time.Millisecond),
+		WithRetryLimit(3),
+	)
+
+	uploadURL, err := url.Parse(srv.URL + "/upload")
+	if err != nil {
+		t.Fatalf("failed to parse upload URL: %v", err)
+	}
+	req := &Req{
+		Host:       "registry.example.org",
+		Method:     http.MethodPut,
+		DirectURL:  uploadURL,
+		BodyLen:    int64(len(data)),
+		BodySeeker: bytes.NewReader(data),
+		ChunkSize:  int64(len(data)), // a single chunk is enough to exercise the retry
+		Query:      url.Values{"digest": {"sha256:deadbeef"}},
+	}
+	resp, err := c.Do(context.Background(), req)
+	if err != nil {
+		t.Fatalf("doChunked failed: %v", err)
+	}
+	defer resp.Close()
+
+	if patchAttempts != 2 {
+		t.Fatalf("expected 2 PATCH attempts (1 failure + 1 retry), got %d", patchAttempts)
+	}
+	for i, body := range patchBodies {
+		if !bytes.Equal(body, data) {
+			t.Fatalf("PATCH attempt %d sent %q, want %q (stale body reader on retry)", i+1, body, data)
+		}
+	}
+	if !commitSeen {
+		t.Fatal("expected a final commit PUT after the chunked upload completed")
+	}
+	if got := commitQuery.Get("digest"); got != "sha256:deadbeef" {
+		t.Fatalf("final commit PUT digest query = %q, want %q", got, "sha256:deadbeef")
+	}
+}
+
+// TestDoChunkedAbortsOnNoProgress covers the stall guard: a PATCH that always fails and a
+// status GET that never reports a committed byte should abort after a bounded number of
+// resume attempts instead of looping forever.
+func TestDoChunkedAbortsOnNoProgress(t *testing.T) {
+	data := []byte("the quick brown fox jumps over the lazy dog")
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	mux.HandleFunc("/upload", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPatch:
+			_, _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusInternalServerError)
+		case http.MethodGet:
+			// no Range header: the server never reports any committed offset
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected method on /upload: %s", r.Method)
+		}
+	})
+
+	c := NewClient(
+		WithConfigHost(func(host string) *config.Host { return &config.Host{Name: host} }),
+		WithDelay(time.Millisecond, 2*time.Millisecond),
+		WithRetryLimit(1),
+	)
+
+	uploadURL, err := url.Parse(srv.URL + "/upload")
+	if err != nil {
+		t.Fatalf("failed to parse upload URL: %v", err)
+	}
+	req := &Req{
+		Host:       "registry.example.org",
+		Method:     http.MethodPut,
+		DirectURL:  uploadURL,
+		BodyLen:    int64(len(data)),
+		BodySeeker: bytes.NewReader(data),
+		ChunkSize:  int64(len(data)),
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.Do(context.Background(), req)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected an error for a chunked upload that never progresses")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("doChunked did not abort a stalled upload within the test timeout")
+	}
+}